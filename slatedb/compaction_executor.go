@@ -0,0 +1,249 @@
+package slatedb
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/samber/mo"
+	"github.com/slatedb/slatedb-go/slatedb/logger"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/zap"
+)
+
+// mergeMaxFetchTasks and mergeNumBlocksToFetch are the read-ahead
+// parameters passed to newSortedRunIterator when a compaction reads its
+// sources; they mirror the values the existing tests use.
+const (
+	mergeMaxFetchTasks    = 1
+	mergeNumBlocksToFetch = 1
+)
+
+// compactionExecutor owns the resources needed to actually run compactions
+// submitted to CompactorState: reading every source's entries in internal
+// key order, keeping only the newest version of each user key (unless an
+// open Snapshot still needs an older one), and writing the merged result
+// as a new output SST.
+type compactionExecutor struct {
+	state      *CompactorState
+	tableStore *TableStore
+	bucket     objstore.Bucket
+	options    compactionTransactOptions
+}
+
+func newCompactionExecutor(state *CompactorState, tableStore *TableStore, bucket objstore.Bucket) *compactionExecutor {
+	return &compactionExecutor{
+		state:      state,
+		tableStore: tableStore,
+		bucket:     bucket,
+		options:    defaultCompactionTransactOptions(),
+	}
+}
+
+// run executes the compaction already submitted for destination and
+// commits the merged result via CompactorState.finishCompaction. If the
+// transact ultimately fails, it reverts any partial output and drops the
+// compaction so the picker submits it again on a later pass.
+func (e *compactionExecutor) run(ctx context.Context, destination uint32) {
+	compaction, ok := e.state.getCompaction(destination)
+	if !ok {
+		return
+	}
+
+	transact := newSSTCompactionTransact(&compaction, e.tableStore, e.bucket, e.state, e.merge(&compaction))
+	sr, err := runCompactionTransact(ctx, transact, e.options)
+	if err != nil {
+		logger.Error("compaction failed permanently, reverting",
+			zap.Uint32("destination", destination), zap.Error(err))
+		if abortErr := e.state.abortCompaction(destination, transact); abortErr != nil {
+			logger.Error("unable to abort failed compaction",
+				zap.Uint32("destination", destination), zap.Error(abortErr))
+		}
+		return
+	}
+
+	e.state.finishCompaction(sr)
+}
+
+// merge returns the CompactionTransact run function: a streaming k-way
+// merge across compaction's sources, ordered by their raw internal keys
+// (user key ascending, then seq descending since encodeInternalKey
+// inverts seq). For each user key it keeps the newest version, and, while a
+// Snapshot is open, every version newer than minLiveSeq plus the newest
+// version at or below minLiveSeq - matching goleveldb's rule that a snapshot
+// must see the version live as of its own seq, not merely any version newer
+// than it.
+func (e *compactionExecutor) merge(
+	compaction *Compaction,
+) func(ctx context.Context, progress *CompactionProgress, minLiveSeq uint64, hasMinLiveSeq bool) (*SortedRun, error) {
+	return func(ctx context.Context, progress *CompactionProgress, minLiveSeq uint64, hasMinLiveSeq bool) (*SortedRun, error) {
+		cursors, err := e.openCursors(compaction.sources)
+		if err != nil {
+			return nil, err
+		}
+		defer closeCursors(cursors)
+
+		mergeHeap := &cursorHeap{cursors: cursors}
+		heap.Init(mergeHeap)
+
+		writer := e.tableStore.tableWriter(newSSTableIDCompacted(ulid.Make()))
+		var lastUserKey []byte
+		haveLast := false
+		// keptForSnapshot is true once this user key has already written the
+		// newest version at or below minLiveSeq, so later (strictly older)
+		// versions of the same key are dropped instead of kept again.
+		keptForSnapshot := false
+
+		for mergeHeap.Len() > 0 {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			cursor := mergeHeap.cursors[0]
+			entry, _ := cursor.cur.Get()
+			userKey, seq := decodeInternalKey(entry.Key)
+			progress.BytesRead.Add(uint64(len(entry.Key) + len(entry.Value)))
+
+			isNewestVersion := !haveLast || !bytes.Equal(userKey, lastUserKey)
+			if isNewestVersion {
+				keptForSnapshot = false
+			}
+			keepForSnapshot := hasMinLiveSeq && (seq > minLiveSeq || !keptForSnapshot)
+			if isNewestVersion || keepForSnapshot {
+				if err := writer.add(entry.Key, mo.Some(entry.Value)); err != nil {
+					return nil, err
+				}
+				progress.BytesWritten.Add(uint64(len(entry.Key) + len(entry.Value)))
+				progress.KeysProcessed.Add(1)
+				if hasMinLiveSeq && seq <= minLiveSeq {
+					keptForSnapshot = true
+				}
+			}
+			lastUserKey = userKey
+			haveLast = true
+
+			if err := cursor.advance(); err != nil {
+				return nil, err
+			}
+			if cursor.cur.IsAbsent() {
+				heap.Pop(mergeHeap)
+			} else {
+				heap.Fix(mergeHeap, 0)
+			}
+		}
+
+		sst, err := writer.close()
+		if err != nil {
+			return nil, err
+		}
+		outputID, _ := sst.id.compactedID().Get()
+		// record on the shared pointer first so Revert (which reads
+		// compaction.outputs through this same *Compaction) can find it if
+		// this compaction is later aborted; state.recordCompactionOutput
+		// separately updates CompactorState's own tracked copy and the
+		// manifest.
+		compaction.recordOutput(outputID)
+		e.state.recordCompactionOutput(compaction.destination, outputID)
+
+		return &SortedRun{id: compaction.destination, sstList: []SSTableHandle{*sst}}, nil
+	}
+}
+
+func (e *compactionExecutor) openCursors(sources []SourceID) ([]*sourceCursor, error) {
+	cursors := make([]*sourceCursor, 0, len(sources))
+	for _, src := range sources {
+		sr, ok := e.resolveSource(src)
+		if !ok {
+			continue
+		}
+		cursor := &sourceCursor{iter: newSortedRunIterator(sr, e.tableStore, mergeMaxFetchTasks, mergeNumBlocksToFetch)}
+		if err := cursor.advance(); err != nil {
+			closeCursors(cursors)
+			return nil, err
+		}
+		if cursor.cur.IsPresent() {
+			cursors = append(cursors, cursor)
+		}
+	}
+	return cursors, nil
+}
+
+// resolveSource looks up the SortedRun (or, for an L0 SST, a synthetic
+// single-SST SortedRun) a SourceID refers to in the compactor's current
+// dbState.
+func (e *compactionExecutor) resolveSource(src SourceID) (SortedRun, bool) {
+	dbState := e.state.dbStateView()
+	if id, ok := src.sortedRunID().Get(); ok {
+		for _, sr := range dbState.compacted {
+			if sr.id == id {
+				return sr, true
+			}
+		}
+		return SortedRun{}, false
+	}
+	if id, ok := src.sstID().Get(); ok {
+		for _, sst := range dbState.l0 {
+			sstID, ok := sst.id.compactedID().Get()
+			if ok && sstID == id {
+				return SortedRun{id: 0, sstList: []SSTableHandle{sst}}, true
+			}
+		}
+	}
+	return SortedRun{}, false
+}
+
+// sourceCursor is one compaction source's read position: the entry it's
+// currently parked on, advanced one at a time by the merge heap.
+type sourceCursor struct {
+	iter *SortedRunIterator
+	cur  mo.Option[KeyValue]
+}
+
+func (c *sourceCursor) advance() error {
+	next, err := c.iter.Next()
+	if err != nil {
+		return err
+	}
+	c.cur = next
+	return nil
+}
+
+func closeCursors(cursors []*sourceCursor) {
+	for _, cursor := range cursors {
+		if err := cursor.iter.Close(); err != nil {
+			logger.Error("unable to close compaction source iterator", zap.Error(err))
+		}
+	}
+}
+
+// cursorHeap is a container/heap.Interface over sourceCursors ordered by
+// their current entry's key, giving the merge a total order across every
+// source without materializing all of them at once.
+type cursorHeap struct {
+	cursors []*sourceCursor
+}
+
+func (h *cursorHeap) Len() int { return len(h.cursors) }
+
+func (h *cursorHeap) Less(i, j int) bool {
+	ei, _ := h.cursors[i].cur.Get()
+	ej, _ := h.cursors[j].cur.Get()
+	return bytes.Compare(ei.Key, ej.Key) < 0
+}
+
+func (h *cursorHeap) Swap(i, j int) {
+	h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i]
+}
+
+func (h *cursorHeap) Push(x any) {
+	h.cursors = append(h.cursors, x.(*sourceCursor))
+}
+
+func (h *cursorHeap) Pop() any {
+	old := h.cursors
+	n := len(old)
+	item := old[n-1]
+	h.cursors = old[:n-1]
+	return item
+}
@@ -0,0 +1,46 @@
+package slatedb
+
+import (
+	"bytes"
+
+	"github.com/slatedb/slatedb-go/slatedb/common"
+)
+
+// keyRange is an inclusive [first, last] user-key range, used to decide
+// whether two SSTs / SortedRuns could contain overlapping user keys.
+type keyRange struct {
+	first []byte
+	last  []byte
+}
+
+func sstKeyRange(sst SSTableHandle) keyRange {
+	first, _ := sst.firstKey().Get()
+	last, _ := sst.lastKey().Get()
+	return keyRange{first: first, last: last}
+}
+
+// sortedRunKeyRange relies on a SortedRun's SSTs being stored in key order,
+// so the range is just the first SST's first key and the last SST's last
+// key.
+func sortedRunKeyRange(sr SortedRun) keyRange {
+	common.AssertTrue(len(sr.sstList) > 0, "sorted run has no SSTs")
+	return keyRange{
+		first: sstKeyRange(sr.sstList[0]).first,
+		last:  sstKeyRange(sr.sstList[len(sr.sstList)-1]).last,
+	}
+}
+
+func (r keyRange) overlaps(other keyRange) bool {
+	return bytes.Compare(r.first, other.last) <= 0 && bytes.Compare(other.first, r.last) <= 0
+}
+
+func (r keyRange) merge(other keyRange) keyRange {
+	merged := r
+	if bytes.Compare(other.first, merged.first) < 0 {
+		merged.first = other.first
+	}
+	if bytes.Compare(other.last, merged.last) > 0 {
+		merged.last = other.last
+	}
+	return merged
+}
@@ -0,0 +1,270 @@
+package slatedb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/slatedb/slatedb-go/slatedb/logger"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/zap"
+)
+
+// compactionRecordKind distinguishes the compaction lifecycle events
+// appended to the manifest, analogous to leveldb's session/manifest log.
+type compactionRecordKind string
+
+const (
+	recordSubmitCompaction compactionRecordKind = "submit_compaction"
+	recordCompactionOutput compactionRecordKind = "compaction_output"
+	recordFinishCompaction compactionRecordKind = "finish_compaction"
+	recordAbortCompaction  compactionRecordKind = "abort_compaction"
+	recordBase             compactionRecordKind = "base"
+)
+
+// compactionManifestRecord is one entry in the append-only compaction
+// manifest log. Only the fields relevant to Kind are populated.
+type compactionManifestRecord struct {
+	Kind        compactionRecordKind `json:"kind"`
+	Destination uint32               `json:"destination,omitempty"`
+	Sources     []string             `json:"sources,omitempty"`
+	OutputSST   string               `json:"output_sst,omitempty"`
+	OutputSSTs  []string             `json:"output_ssts,omitempty"`
+
+	// BaseCompactions is only set on a recordBase entry: the set of
+	// compactions still open as of the snapshot, so replay doesn't have to
+	// walk the whole GC'd tail of the log.
+	BaseCompactions map[uint32]openCompactionState `json:"base_compactions,omitempty"`
+}
+
+// openCompactionState is the replay-time bookkeeping for one still-open
+// compaction: its original Sources (needed to resume it, since
+// finishCompaction requires a non-empty source set) and whatever output
+// SSTs have been recorded for it so far.
+type openCompactionState struct {
+	Sources []string `json:"sources,omitempty"`
+	Outputs []string `json:"outputs,omitempty"`
+}
+
+// compactionManifestStore persists compaction lifecycle records as a
+// sequence of numbered objects under dir in bucket, and replays them to
+// rebuild CompactorState.compactions after a restart.
+type compactionManifestStore struct {
+	bucket objstore.Bucket
+	dir    string
+
+	// mu serializes appendRecord's nextSeq-then-Upload so two concurrent
+	// appends (e.g. a submit from the scheduler and a finish from an
+	// executor) never compute the same sequence number and silently
+	// overwrite each other's record.
+	mu sync.Mutex
+}
+
+func newCompactionManifestStore(bucket objstore.Bucket, dir string) *compactionManifestStore {
+	return &compactionManifestStore{bucket: bucket, dir: strings.TrimSuffix(dir, "/")}
+}
+
+func (m *compactionManifestStore) objectName(seq uint64) string {
+	return fmt.Sprintf("%s/%020d.json", m.dir, seq)
+}
+
+// nextSeq returns one past the highest sequence number currently present in
+// the manifest directory.
+func (m *compactionManifestStore) nextSeq(ctx context.Context) (uint64, error) {
+	names, err := m.listObjects(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+	last := names[len(names)-1]
+	var seq uint64
+	base := strings.TrimSuffix(last[len(m.dir)+1:], ".json")
+	if _, err := fmt.Sscanf(base, "%d", &seq); err != nil {
+		return 0, fmt.Errorf("unable to parse manifest object name %q: %w", last, err)
+	}
+	return seq + 1, nil
+}
+
+func (m *compactionManifestStore) listObjects(ctx context.Context) ([]string, error) {
+	var names []string
+	err := m.bucket.Iter(ctx, m.dir, func(name string) error {
+		if strings.HasSuffix(name, ".json") {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *compactionManifestStore) appendRecord(ctx context.Context, record compactionManifestRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seq, err := m.nextSeq(ctx)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return m.bucket.Upload(ctx, m.objectName(seq), strings.NewReader(string(encoded)))
+}
+
+func (m *compactionManifestStore) loadRecords(ctx context.Context) ([]string, []compactionManifestRecord, error) {
+	names, err := m.listObjects(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records := make([]compactionManifestRecord, 0, len(names))
+	for _, name := range names {
+		reader, err := m.bucket.Get(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		raw, err := io.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		var record compactionManifestRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, nil, fmt.Errorf("unable to decode manifest record %q: %w", name, err)
+		}
+		records = append(records, record)
+	}
+	return names, records, nil
+}
+
+// openCompaction is the replay-time record of one still-open compaction:
+// its original source IDs (so it can actually be resumed - finishCompaction
+// requires a non-empty source set) and whatever output SSTs have been
+// recorded for it so far.
+type openCompaction struct {
+	sources []string
+	outputs []ulid.ULID
+}
+
+// replayOpenCompactions folds records into the set of compactions that have
+// been submitted but neither finished nor aborted, together with their
+// original sources and whatever output SSTs have been recorded for each so
+// far.
+func replayOpenCompactions(records []compactionManifestRecord) map[uint32]openCompaction {
+	open := make(map[uint32]openCompaction)
+
+	for _, record := range records {
+		switch record.Kind {
+		case recordBase:
+			open = make(map[uint32]openCompaction, len(record.BaseCompactions))
+			for dest, state := range record.BaseCompactions {
+				open[dest] = openCompaction{sources: state.Sources, outputs: parseULIDs(state.Outputs)}
+			}
+		case recordSubmitCompaction:
+			open[record.Destination] = openCompaction{sources: record.Sources}
+		case recordCompactionOutput:
+			if id, err := ulid.Parse(record.OutputSST); err == nil {
+				entry := open[record.Destination]
+				entry.outputs = append(entry.outputs, id)
+				open[record.Destination] = entry
+			}
+		case recordFinishCompaction, recordAbortCompaction:
+			delete(open, record.Destination)
+		}
+	}
+	return open
+}
+
+func parseULIDs(encoded []string) []ulid.ULID {
+	ids := make([]ulid.ULID, 0, len(encoded))
+	for _, s := range encoded {
+		if id, err := ulid.Parse(s); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// recoverOpenCompactions is called once at compactor startup, against a
+// state whose dbState and manifest are already set. Every compaction left
+// open by a prior run is driven to a definite outcome right away, the same
+// way a live compactor would, rather than merely re-tracked: if its sources
+// still parse, it is re-run to completion through a throwaway
+// compactionExecutor - which either finishes it into dbState on success or
+// reverts its (fresh) output on failure - so the destination is never left
+// wedged for a future submitCompaction. Any output the prior run had already
+// recorded is superseded by that rerun and deleted first; sources that no
+// longer parse leave nothing worth re-running, so the compaction is simply
+// dropped.
+func recoverOpenCompactions(ctx context.Context, bucket objstore.Bucket, tableStore *TableStore, state *CompactorState, open map[uint32]openCompaction) {
+	executor := newCompactionExecutor(state, tableStore, bucket)
+
+	for destination, entry := range open {
+		for _, id := range entry.outputs {
+			if err := tableStore.delete(newSSTableIDCompacted(id)); err != nil {
+				logger.Error("unable to delete superseded compaction output during recovery",
+					zap.String("sst_id", id.String()), zap.Error(err))
+			}
+		}
+
+		sources := parseSourceIDs(entry.sources)
+		if len(sources) == 0 {
+			logger.Info("dropped unrecoverable in-flight compaction from manifest", zap.Uint32("destination", destination))
+			continue
+		}
+
+		state.compactions[destination] = newCompaction(sources, destination)
+		logger.Info("resuming in-flight compaction from manifest", zap.Uint32("destination", destination))
+		executor.run(ctx, destination)
+	}
+}
+
+// compact snapshots any still-open compactions into a new recordBase entry,
+// then deletes every manifest object that predates it, bounding how much of
+// the log replay has to walk on the next restart. dbState itself is not
+// recorded in the base entry.
+func (m *compactionManifestStore) compact(ctx context.Context, state *CompactorState) error {
+	names, _, err := m.loadRecords(ctx)
+	if err != nil {
+		return err
+	}
+
+	state.mu.Lock()
+	baseCompactions := make(map[uint32]openCompactionState, len(state.compactions))
+	for destination, compaction := range state.compactions {
+		outputs := make([]string, 0, len(compaction.outputs))
+		for _, id := range compaction.outputs {
+			outputs = append(outputs, id.String())
+		}
+		baseCompactions[destination] = openCompactionState{
+			Sources: sourceStrings(compaction.sources),
+			Outputs: outputs,
+		}
+	}
+	state.mu.Unlock()
+
+	if err := m.appendRecord(ctx, compactionManifestRecord{
+		Kind:            recordBase,
+		BaseCompactions: baseCompactions,
+	}); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := m.bucket.Delete(ctx, name); err != nil {
+			logger.Error("unable to delete GC'd manifest record", zap.String("name", name), zap.Error(err))
+		}
+	}
+	return nil
+}
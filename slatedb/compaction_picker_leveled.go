@@ -0,0 +1,138 @@
+package slatedb
+
+import (
+	"github.com/samber/mo"
+)
+
+// leveledCompactionPicker picks compactions the way goleveldb's
+// db_compaction.go does: L0 is special-cased (it triggers purely on file
+// count since its SSTs may overlap), while each SortedRun beyond that has a
+// target size of baseLevelSize * sizeMultiplier^level. The level with the
+// highest score (currentSize/targetSize, or l0 count/l0Trigger for L0) that
+// is >= 1.0 is compacted into the level below it.
+type leveledCompactionPicker struct {
+	l0CompactionTrigger uint64
+	baseLevelSize       uint64
+	sizeMultiplier      uint64
+	maxConcurrent       int
+}
+
+// defaultBaseLevelSize and defaultSizeMultiplier are in the same unit as
+// sortedRunSize (SST count), not bytes: SSTableHandle does not expose a real
+// on-disk size to this package, so targetSizeForLevel scores on SST count
+// the same way l0Score does. A base of 4 keeps a SortedRun's score reaching
+// 1.0 at roughly the same L0 fan-in as l0CompactionTrigger, instead of
+// requiring dozens of tiny SSTs before L1+ compaction ever triggers.
+const (
+	defaultBaseLevelSize  = 4
+	defaultSizeMultiplier = 4
+)
+
+func newLeveledCompactionPicker(options CompactorOptions) *leveledCompactionPicker {
+	trigger := options.L0CompactionTrigger
+	if trigger == 0 {
+		trigger = defaultCompactorOptions().L0CompactionTrigger
+	}
+	maxConcurrent := options.MaxConcurrentCompactions
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultCompactorOptions().MaxConcurrentCompactions
+	}
+	return &leveledCompactionPicker{
+		l0CompactionTrigger: trigger,
+		baseLevelSize:       defaultBaseLevelSize,
+		sizeMultiplier:      defaultSizeMultiplier,
+		maxConcurrent:       maxConcurrent,
+	}
+}
+
+func (p *leveledCompactionPicker) MaxConcurrent() int {
+	return p.maxConcurrent
+}
+
+func (p *leveledCompactionPicker) Pick(state *CoreDBState) []Compaction {
+	bestLevel := -1
+	bestScore := 1.0
+
+	l0Score := float64(len(state.l0)) / float64(p.l0CompactionTrigger)
+	if l0Score >= bestScore {
+		bestLevel = -1
+		bestScore = l0Score
+	}
+
+	for level, sr := range state.compacted {
+		score := float64(sortedRunSize(sr)) / float64(p.targetSizeForLevel(level))
+		if score >= bestScore {
+			bestLevel = level
+			bestScore = score
+		}
+	}
+
+	if bestLevel == -1 && l0Score < 1.0 {
+		return nil
+	}
+
+	compaction := p.pickCompactionAtLevel(state, bestLevel)
+	if compaction.IsAbsent() {
+		return nil
+	}
+	result, _ := compaction.Get()
+	return []Compaction{result}
+}
+
+// targetSizeForLevel mirrors goleveldb's exponential level sizing: each
+// level beyond the base holds sizeMultiplier times as much as the level
+// above it.
+func (p *leveledCompactionPicker) targetSizeForLevel(level int) uint64 {
+	target := p.baseLevelSize
+	for i := 0; i < level; i++ {
+		target *= p.sizeMultiplier
+	}
+	return target
+}
+
+// firstSortedRunID is the destination id the leveled picker gives L0's very
+// first compaction, when there isn't yet a shallowest SortedRun for it to
+// overwrite.
+const firstSortedRunID = 0
+
+// pickCompactionAtLevel builds the Compaction that merges level into the
+// SortedRun below it (or, for level == -1, merges all of L0 into the
+// shallowest SortedRun, creating it at firstSortedRunID if none exists yet).
+// The destination keeps the lower level's id so CompactorState.submitCompaction
+// recognizes it as an overwrite.
+func (p *leveledCompactionPicker) pickCompactionAtLevel(state *CoreDBState, level int) mo.Option[Compaction] {
+	if level == -1 {
+		if len(state.l0) == 0 {
+			return mo.None[Compaction]()
+		}
+		sources := make([]SourceID, 0, len(state.l0)+1)
+		for _, sst := range state.l0 {
+			id, ok := sst.id.compactedID().Get()
+			if !ok {
+				continue
+			}
+			sources = append(sources, newSourceIDSST(id))
+		}
+		if len(state.compacted) == 0 {
+			return mo.Some(newCompaction(sources, firstSortedRunID))
+		}
+		dest := state.compacted[0]
+		sources = append(sources, newSourceIDSortedRun(dest.id))
+		return mo.Some(newCompaction(sources, dest.id))
+	}
+
+	if level+1 >= len(state.compacted) {
+		return mo.None[Compaction]()
+	}
+	src := state.compacted[level]
+	dest := state.compacted[level+1]
+	sources := []SourceID{newSourceIDSortedRun(src.id), newSourceIDSortedRun(dest.id)}
+	return mo.Some(newCompaction(sources, dest.id))
+}
+
+// sortedRunSize is a SortedRun's size in the same SST-count unit as
+// targetSizeForLevel; see the comment on defaultBaseLevelSize for why this
+// isn't bytes.
+func sortedRunSize(sr SortedRun) uint64 {
+	return uint64(len(sr.sstList))
+}
@@ -0,0 +1,68 @@
+package slatedb
+
+// tieredCompactionPicker merges runs of similar size, the way an
+// RocksDB/Cassandra-style tiered strategy does: once at least minRunsPerTier
+// SortedRuns of roughly the same size exist, they are merged into a single,
+// larger run one level deeper. Unlike the leveled picker it never compacts a
+// run on its own, so write amplification stays low at the cost of more space
+// and read amplification.
+type tieredCompactionPicker struct {
+	minRunsPerTier uint64
+	maxConcurrent  int
+}
+
+// sizeRatioWithinTier bounds how far apart two runs' sizes may be and still
+// be considered part of the same tier.
+const sizeRatioWithinTier = 2
+
+func newTieredCompactionPicker(options CompactorOptions) *tieredCompactionPicker {
+	minRuns := options.MinRunsPerTier
+	if minRuns == 0 {
+		minRuns = defaultCompactorOptions().MinRunsPerTier
+	}
+	maxConcurrent := options.MaxConcurrentCompactions
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultCompactorOptions().MaxConcurrentCompactions
+	}
+	return &tieredCompactionPicker{
+		minRunsPerTier: minRuns,
+		maxConcurrent:  maxConcurrent,
+	}
+}
+
+func (p *tieredCompactionPicker) MaxConcurrent() int {
+	return p.maxConcurrent
+}
+
+func (p *tieredCompactionPicker) Pick(state *CoreDBState) []Compaction {
+	compacted := state.compacted
+	if uint64(len(compacted)) < p.minRunsPerTier {
+		return nil
+	}
+
+	tierStart := 0
+	for i := 1; i <= len(compacted); i++ {
+		if i < len(compacted) &&
+			sortedRunSize(compacted[i]) <= sortedRunSize(compacted[tierStart])*sizeRatioWithinTier &&
+			sortedRunSize(compacted[tierStart]) <= sortedRunSize(compacted[i])*sizeRatioWithinTier {
+			continue
+		}
+		if uint64(i-tierStart) >= p.minRunsPerTier {
+			return []Compaction{p.mergeTier(compacted[tierStart:i])}
+		}
+		tierStart = i
+	}
+	return nil
+}
+
+// mergeTier merges every run in the tier into the deepest (lowest id) run's
+// slot, matching CompactorState.submitCompaction's expectation that an
+// overwritten destination is included among its own sources.
+func (p *tieredCompactionPicker) mergeTier(tier []SortedRun) Compaction {
+	sources := make([]SourceID, 0, len(tier))
+	for _, sr := range tier {
+		sources = append(sources, newSourceIDSortedRun(sr.id))
+	}
+	destination := tier[len(tier)-1].id
+	return newCompaction(sources, destination)
+}
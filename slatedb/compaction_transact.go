@@ -0,0 +1,217 @@
+package slatedb
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/slatedb/slatedb-go/slatedb/logger"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/zap"
+)
+
+// errCompactionStalled is returned when a CompactionTransact reports no
+// progress for longer than compactionTransactOptions.stallTimeout.
+var errCompactionStalled = errors.New("compaction transact stalled")
+
+// CompactionProgress carries counters a running CompactionTransact updates
+// as it works, so the compactor can report progress and detect a stall. The
+// counters are updated from the transact's own goroutine and read from the
+// stall-detection goroutine concurrently, so they're atomic.Uint64 rather
+// than plain uint64.
+type CompactionProgress struct {
+	BytesRead     atomic.Uint64
+	BytesWritten  atomic.Uint64
+	KeysProcessed atomic.Uint64
+}
+
+// progressSnapshot is a point-in-time, comparable reading of
+// CompactionProgress's counters.
+type progressSnapshot struct {
+	bytesRead     uint64
+	bytesWritten  uint64
+	keysProcessed uint64
+}
+
+func (p *CompactionProgress) snapshot() progressSnapshot {
+	return progressSnapshot{
+		bytesRead:     p.BytesRead.Load(),
+		bytesWritten:  p.BytesWritten.Load(),
+		keysProcessed: p.KeysProcessed.Load(),
+	}
+}
+
+func (s progressSnapshot) equal(other progressSnapshot) bool {
+	return s == other
+}
+
+// CompactionTransact executes a single compaction and can cleanly back out
+// any output SSTs it has uploaded if the compaction is aborted, modeled on
+// goleveldb's compactionTransact/revert pattern.
+type CompactionTransact interface {
+	// Run merges the compaction's sources into a new SortedRun, updating
+	// progress as it reads input and writes output. It must be safe to call
+	// Revert after Run returns an error or ctx is cancelled mid-flight.
+	Run(ctx context.Context, progress *CompactionProgress) (*SortedRun, error)
+
+	// Revert deletes any output SSTs this transact has already uploaded to
+	// the bucket. It must leave CompactorState.compactions and dbState
+	// untouched; the caller is responsible for forgetting the compaction.
+	Revert() error
+}
+
+// sstCompactionTransact is the CompactionTransact used for real compactions:
+// it merges compaction.sources into one or more output SSTs via tableStore
+// and tracks their ULIDs on compaction so Revert can delete them.
+type sstCompactionTransact struct {
+	compaction *Compaction
+	tableStore *TableStore
+	bucket     objstore.Bucket
+
+	// state is consulted for minLiveSnapshotSeq before run merges sources,
+	// so that a version of a key still visible to an open Snapshot is
+	// never dropped, even if a newer version of the same key also
+	// survives.
+	state *CompactorState
+	run   func(ctx context.Context, progress *CompactionProgress, minLiveSeq uint64, hasMinLiveSeq bool) (*SortedRun, error)
+}
+
+func newSSTCompactionTransact(
+	compaction *Compaction,
+	tableStore *TableStore,
+	bucket objstore.Bucket,
+	state *CompactorState,
+	run func(ctx context.Context, progress *CompactionProgress, minLiveSeq uint64, hasMinLiveSeq bool) (*SortedRun, error),
+) *sstCompactionTransact {
+	return &sstCompactionTransact{
+		compaction: compaction,
+		tableStore: tableStore,
+		bucket:     bucket,
+		state:      state,
+		run:        run,
+	}
+}
+
+func (t *sstCompactionTransact) Run(ctx context.Context, progress *CompactionProgress) (*SortedRun, error) {
+	minLiveSeq, hasMinLiveSeq := t.state.minLiveSnapshotSeq()
+	return t.run(ctx, progress, minLiveSeq, hasMinLiveSeq)
+}
+
+func (t *sstCompactionTransact) Revert() error {
+	for _, id := range t.compaction.outputs {
+		sstID := newSSTableIDCompacted(id)
+		if err := t.tableStore.delete(sstID); err != nil {
+			logger.Error("unable to delete orphaned compaction output",
+				zap.String("sst_id", id.String()), zap.Error(err))
+			return err
+		}
+	}
+	t.compaction.outputs = nil
+	return nil
+}
+
+// compactionTransactOptions tunes the retry/backoff and stall-detection
+// behaviour of runCompactionTransact.
+type compactionTransactOptions struct {
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	stallTimeout time.Duration
+}
+
+func defaultCompactionTransactOptions() compactionTransactOptions {
+	return compactionTransactOptions{
+		maxAttempts:  5,
+		baseBackoff:  100 * time.Millisecond,
+		maxBackoff:   10 * time.Second,
+		stallTimeout: 30 * time.Second,
+	}
+}
+
+// runCompactionTransact runs transact.Run, retrying with exponential
+// backoff on error, and reverting whatever it uploaded before each retry
+// and before giving up. It also aborts (and reverts) if progress stops
+// advancing for longer than stallTimeout.
+func runCompactionTransact(
+	ctx context.Context,
+	transact CompactionTransact,
+	options compactionTransactOptions,
+) (*SortedRun, error) {
+	var lastErr error
+	backoff := options.baseBackoff
+
+	for attempt := 1; attempt <= options.maxAttempts; attempt++ {
+		progress := &CompactionProgress{}
+		sr, err := runOnceWithStallDetection(ctx, transact, progress, options.stallTimeout)
+		if err == nil {
+			return sr, nil
+		}
+
+		lastErr = err
+		logger.Error("compaction transact failed, reverting",
+			zap.Int("attempt", attempt), zap.Error(err))
+		if revertErr := transact.Revert(); revertErr != nil {
+			logger.Error("unable to revert failed compaction", zap.Error(revertErr))
+			return nil, revertErr
+		}
+
+		if attempt == options.maxAttempts || ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+		if backoff > options.maxBackoff {
+			backoff = options.maxBackoff
+		}
+	}
+
+	return nil, lastErr
+}
+
+// runOnceWithStallDetection runs transact.Run on a background goroutine and
+// fails fast if progress hasn't advanced within stallTimeout, rather than
+// waiting indefinitely on a wedged writer or object store call.
+func runOnceWithStallDetection(
+	ctx context.Context,
+	transact CompactionTransact,
+	progress *CompactionProgress,
+	stallTimeout time.Duration,
+) (*SortedRun, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		sr  *SortedRun
+		err error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		sr, err := transact.Run(runCtx, progress)
+		resultCh <- result{sr, err}
+	}()
+
+	ticker := time.NewTicker(stallTimeout)
+	defer ticker.Stop()
+
+	last := progress.snapshot()
+	for {
+		select {
+		case res := <-resultCh:
+			return res.sr, res.err
+		case <-ticker.C:
+			current := progress.snapshot()
+			if current.equal(last) {
+				cancel()
+				logger.Error("compaction transact stalled, aborting", zap.Duration("stall_timeout", stallTimeout))
+				return nil, errCompactionStalled
+			}
+			last = current
+		}
+	}
+}
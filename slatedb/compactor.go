@@ -0,0 +1,171 @@
+package slatedb
+
+import (
+	"context"
+	"time"
+
+	"github.com/slatedb/slatedb-go/slatedb/logger"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/zap"
+)
+
+// CompactionStrategy selects which built-in CompactionPicker implementation
+// the Compactor uses to choose compactions.
+type CompactionStrategy int
+
+const (
+	CompactionStrategyLeveled CompactionStrategy = iota + 1
+	CompactionStrategyTiered
+)
+
+// CompactorOptions configures the background compactor.
+type CompactorOptions struct {
+	// PollInterval is how often the compactor asks the picker for new work.
+	PollInterval time.Duration
+
+	// Strategy selects the CompactionPicker implementation used to choose
+	// which L0 SSTs / SortedRuns to compact next.
+	Strategy CompactionStrategy
+
+	// L0CompactionTrigger is the number of L0 SSTs that must accumulate
+	// before the leveled picker will compact L0 into the first SortedRun.
+	L0CompactionTrigger uint64
+
+	// MaxConcurrentCompactions bounds how many compactions may be
+	// in-flight at once, regardless of how many the picker returns.
+	MaxConcurrentCompactions int
+
+	// MinRunsPerTier is the tiered-strategy trigger: the minimum number of
+	// similarly sized runs that must exist in a tier before they are merged.
+	MinRunsPerTier uint64
+}
+
+func defaultCompactorOptions() CompactorOptions {
+	return CompactorOptions{
+		PollInterval:             5 * time.Second,
+		Strategy:                 CompactionStrategyLeveled,
+		L0CompactionTrigger:      4,
+		MaxConcurrentCompactions: 4,
+		MinRunsPerTier:           4,
+	}
+}
+
+// CompactionPicker chooses the next compactions to run against a CoreDBState
+// snapshot. Implementations must treat state as read-only.
+type CompactionPicker interface {
+	// Pick returns zero or more Compactions the compactor should submit,
+	// ordered from most to least urgent.
+	Pick(state *CoreDBState) []Compaction
+
+	// MaxConcurrent bounds how many of the Compactions returned by Pick may
+	// be submitted before earlier ones finish.
+	MaxConcurrent() int
+}
+
+func newCompactionPicker(options CompactorOptions) CompactionPicker {
+	switch options.Strategy {
+	case CompactionStrategyTiered:
+		return newTieredCompactionPicker(options)
+	default:
+		return newLeveledCompactionPicker(options)
+	}
+}
+
+// Compactor drives compactions in the background by repeatedly polling a
+// CompactionPicker, submitting whatever it returns to CompactorState, and
+// running each submitted compaction to completion via a compactionExecutor.
+type Compactor struct {
+	options  CompactorOptions
+	picker   CompactionPicker
+	state    *CompactorState
+	executor *compactionExecutor
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// newCompactor builds a Compactor that submits compactions chosen by
+// options.Strategy against state and runs them with tableStore/bucket.
+// Callers start it by calling scheduleCompactions in its own goroutine and
+// stop it with close.
+func newCompactor(state *CompactorState, tableStore *TableStore, bucket objstore.Bucket, options CompactorOptions) *Compactor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Compactor{
+		options:  options,
+		picker:   newCompactionPicker(options),
+		state:    state,
+		executor: newCompactionExecutor(state, tableStore, bucket),
+		ctx:      ctx,
+		cancel:   cancel,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// manifestGCInterval is how often scheduleCompactions snapshots the
+// compaction manifest and GCs its tail, relative to PollInterval.
+const manifestGCInterval = 20
+
+// scheduleCompactions polls the picker on options.PollInterval, submitting
+// whatever it returns, until close is called. Every manifestGCInterval
+// polls it also compacts the manifest, if one is configured.
+func (c *Compactor) scheduleCompactions() {
+	defer close(c.doneCh)
+
+	ticker := time.NewTicker(c.options.PollInterval)
+	defer ticker.Stop()
+
+	polls := 0
+	for {
+		select {
+		case <-ticker.C:
+			c.pickAndSubmit()
+			polls++
+			if polls%manifestGCInterval == 0 {
+				c.compactManifest()
+			}
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+func (c *Compactor) compactManifest() {
+	if c.state.manifest == nil {
+		return
+	}
+	if err := c.state.manifest.compact(context.Background(), c.state); err != nil {
+		logger.Error("unable to compact compaction manifest", zap.Error(err))
+	}
+}
+
+func (c *Compactor) pickAndSubmit() {
+	running := len(c.state.getCompactions())
+	maxConcurrent := c.picker.MaxConcurrent()
+	if c.options.MaxConcurrentCompactions > 0 && c.options.MaxConcurrentCompactions < maxConcurrent {
+		maxConcurrent = c.options.MaxConcurrentCompactions
+	}
+
+	for _, compaction := range c.picker.Pick(c.state.dbStateView()) {
+		if running >= maxConcurrent {
+			return
+		}
+		destination := compaction.destination
+		if err := c.state.submitCompaction(compaction); err != nil {
+			logger.Error("unable to submit picked compaction", zap.Error(err), zap.Any("compaction", compaction))
+			continue
+		}
+		running++
+		go c.executor.run(c.ctx, destination)
+	}
+}
+
+// close stops scheduleCompactions, cancels any in-flight compaction execution,
+// and waits for the loop to exit.
+func (c *Compactor) close() {
+	c.cancel()
+	close(c.stopCh)
+	<-c.doneCh
+}
@@ -1,15 +1,20 @@
 package slatedb
 
 import (
+	"bytes"
+	"context"
 	"maps"
 	"math"
 	"slices"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/samber/mo"
 	"github.com/slatedb/slatedb-go/slatedb/common"
 	"github.com/slatedb/slatedb-go/slatedb/logger"
+	"github.com/thanos-io/objstore"
 	"go.uber.org/zap"
 )
 
@@ -82,6 +87,11 @@ type Compaction struct {
 	status      CompactionStatus
 	sources     []SourceID
 	destination uint32
+
+	// outputs records the ULIDs of output SSTs already uploaded to the
+	// object store by this compaction's CompactionTransact, so that a
+	// Revert can find and delete them.
+	outputs []ulid.ULID
 }
 
 func newCompaction(sources []SourceID, destination uint32) Compaction {
@@ -92,27 +102,211 @@ func newCompaction(sources []SourceID, destination uint32) Compaction {
 	}
 }
 
+// recordOutput tracks an output SST that has been uploaded for this
+// compaction, so it can be cleaned up by Revert if the compaction aborts.
+func (c *Compaction) recordOutput(id ulid.ULID) {
+	c.outputs = append(c.outputs, id)
+}
+
 // ------------------------------------------------
 // CompactorState
 // ------------------------------------------------
 
 type CompactorState struct {
+	// mu guards dbState and compactions: both are written by the scheduler
+	// goroutine (submitCompaction, refreshDBState) and read/written by every
+	// compactionExecutor goroutine running a submitted compaction
+	// (recordCompactionOutput, finishCompaction, abortCompaction), so
+	// without it they're a concurrent map access and a torn pointer read.
+	mu          sync.Mutex
 	dbState     *CoreDBState
 	compactions map[uint32]Compaction
+	snapshots   *snapshotRegistry
+
+	// manifest persists compaction lifecycle events so they survive a
+	// compactor restart. It is nil when the state was built without one,
+	// e.g. in tests that don't care about crash recovery.
+	manifest *compactionManifestStore
 }
 
 func newCompactorState(dbState *CoreDBState) *CompactorState {
 	return &CompactorState{
 		dbState:     dbState,
 		compactions: map[uint32]Compaction{},
+		snapshots:   newSnapshotRegistry(),
 	}
 }
 
+// newCompactorStateFromManifest rebuilds a CompactorState by replaying the
+// compaction manifest under dir in bucket, then drives any compaction left
+// open by a prior run to a definite outcome - completed or reverted, never
+// merely re-tracked - via recoverOpenCompactions, so no destination is left
+// permanently wedged.
+func newCompactorStateFromManifest(
+	ctx context.Context,
+	dbState *CoreDBState,
+	bucket objstore.Bucket,
+	tableStore *TableStore,
+	dir string,
+) (*CompactorState, error) {
+	manifest := newCompactionManifestStore(bucket, dir)
+	_, records, err := manifest.loadRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &CompactorState{
+		dbState:     dbState,
+		compactions: map[uint32]Compaction{},
+		snapshots:   newSnapshotRegistry(),
+		manifest:    manifest,
+	}
+
+	open := replayOpenCompactions(records)
+	recoverOpenCompactions(ctx, bucket, tableStore, state, open)
+
+	return state, nil
+}
+
+// newSnapshot registers and returns a Snapshot pinned at seq. The returned
+// Snapshot must be closed once the reader is done with it, or the compactor
+// will keep every version newer than seq around indefinitely.
+func (c *CompactorState) newSnapshot(seq uint64) *Snapshot {
+	return c.snapshots.register(seq)
+}
+
+// minLiveSnapshotSeq returns the oldest seq still visible to an open
+// Snapshot. A compaction's merge must not drop a version of a key newer than
+// this seq, even if a newer version of the same key also survives.
+func (c *CompactorState) minLiveSnapshotSeq() (uint64, bool) {
+	return c.snapshots.minLiveSeq()
+}
+
 func (c *CompactorState) getCompactions() []Compaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return slices.Collect(maps.Values(c.compactions))
 }
 
+// getCompaction returns the currently tracked Compaction for destination, if
+// any.
+func (c *CompactorState) getCompaction(destination uint32) (Compaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	compaction, ok := c.compactions[destination]
+	return compaction, ok
+}
+
+// dbStateView returns the CoreDBState snapshot compactions should read
+// against. dbState is replaced wholesale (never mutated in place) by
+// refreshDBState/finishCompaction, so the pointer returned here is safe for
+// the caller to keep using after dbStateView returns.
+func (c *CompactorState) dbStateView() *CoreDBState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dbState
+}
+
+// appendManifestRecord persists record if this CompactorState was built
+// with a manifest; it is a no-op (used by tests and other callers that
+// don't need crash recovery) when it was not.
+func (c *CompactorState) appendManifestRecord(record compactionManifestRecord) {
+	if c.manifest == nil {
+		return
+	}
+	if err := c.manifest.appendRecord(context.Background(), record); err != nil {
+		logger.Error("unable to append compaction manifest record", zap.Error(err))
+	}
+}
+
+func sourceStrings(sources []SourceID) []string {
+	encoded := make([]string, len(sources))
+	for i, src := range sources {
+		prefix := "sst"
+		if src.typ == SortedRunID {
+			prefix = "sr"
+		}
+		encoded[i] = prefix + ":" + src.value
+	}
+	return encoded
+}
+
+// parseSourceIDs is the inverse of sourceStrings, used when replaying the
+// compaction manifest. It skips (and logs) any entry it can't parse rather
+// than failing the whole replay over one bad record.
+func parseSourceIDs(encoded []string) []SourceID {
+	sources := make([]SourceID, 0, len(encoded))
+	for _, s := range encoded {
+		prefix, value, ok := strings.Cut(s, ":")
+		if !ok {
+			logger.Error("unable to parse compaction source", zap.String("source", s))
+			continue
+		}
+		switch prefix {
+		case "sr":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				logger.Error("unable to parse sorted run source id", zap.String("source", s), zap.Error(err))
+				continue
+			}
+			sources = append(sources, newSourceIDSortedRun(uint32(n)))
+		case "sst":
+			id, err := ulid.Parse(value)
+			if err != nil {
+				logger.Error("unable to parse sst source id", zap.String("source", s), zap.Error(err))
+				continue
+			}
+			sources = append(sources, newSourceIDSST(id))
+		default:
+			logger.Error("unknown compaction source prefix", zap.String("source", s))
+		}
+	}
+	return sources
+}
+
+// recordCompactionOutput tracks an output SST a running compaction's
+// CompactionTransact has just uploaded, both on the in-memory Compaction
+// and (if configured) in the manifest, so a crash recovery replay can later
+// tell which outputs had already landed.
+func (c *CompactorState) recordCompactionOutput(destination uint32, id ulid.ULID) {
+	c.mu.Lock()
+	if compaction, ok := c.compactions[destination]; ok {
+		compaction.recordOutput(id)
+		c.compactions[destination] = compaction
+	}
+	c.mu.Unlock()
+	c.appendManifestRecord(compactionManifestRecord{
+		Kind:        recordCompactionOutput,
+		Destination: destination,
+		OutputSST:   id.String(),
+	})
+}
+
+// abortCompaction reverts a running compaction's uploaded outputs and
+// forgets it, recording the abort in the manifest so replay doesn't try to
+// resume it after a restart.
+func (c *CompactorState) abortCompaction(destination uint32, transact CompactionTransact) error {
+	if err := transact.Revert(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	delete(c.compactions, destination)
+	c.mu.Unlock()
+	c.appendManifestRecord(compactionManifestRecord{
+		Kind:        recordAbortCompaction,
+		Destination: destination,
+	})
+	return nil
+}
+
 func (c *CompactorState) submitCompaction(compaction Compaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// expand the source set first so the checks below see the compaction
+	// that will actually run, not the one the caller asked for.
+	compaction = c.expandSources(compaction)
+
 	_, ok := c.compactions[compaction.destination]
 	if ok {
 		// we already have an ongoing compaction for this destination
@@ -129,11 +323,144 @@ func (c *CompactorState) submitCompaction(compaction Compaction) error {
 		}
 	}
 
+	if err := c.checkNoResidualOverlap(compaction); err != nil {
+		return err
+	}
+
 	logger.Info("accepted submitted compaction:", zap.Any("compaction", compaction))
+	c.appendManifestRecord(compactionManifestRecord{
+		Kind:        recordSubmitCompaction,
+		Destination: compaction.destination,
+		Sources:     sourceStrings(compaction.sources),
+	})
 	c.compactions[compaction.destination] = compaction
 	return nil
 }
 
+// expandSources grows compaction.sources to include every SortedRun / L0
+// SSTableHandle whose key range overlaps the union of the proposed sources'
+// ranges. This mirrors goleveldb's fix for issue #83: without it, a user
+// key could end up split across an included source and an excluded
+// overlapping file, and Get could then return a stale value from the file
+// left behind.
+func (c *CompactorState) expandSources(compaction Compaction) Compaction {
+	union, ok := c.sourcesUnionRange(compaction.sources)
+	if !ok {
+		return compaction
+	}
+
+	sourceSRs, sourceSSTs := sourceSets(compaction.sources)
+	expanded := append([]SourceID(nil), compaction.sources...)
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, sr := range c.dbState.compacted {
+			if sourceSRs[sr.id] {
+				continue
+			}
+			if r := sortedRunKeyRange(sr); r.overlaps(union) {
+				sourceSRs[sr.id] = true
+				expanded = append(expanded, newSourceIDSortedRun(sr.id))
+				union = union.merge(r)
+				changed = true
+			}
+		}
+
+		for _, sst := range c.dbState.l0 {
+			id, ok := sst.id.compactedID().Get()
+			if !ok || sourceSSTs[id] {
+				continue
+			}
+			if r := sstKeyRange(sst); r.overlaps(union) {
+				sourceSSTs[id] = true
+				expanded = append(expanded, newSourceIDSST(id))
+				union = union.merge(r)
+				changed = true
+			}
+		}
+	}
+
+	compaction.sources = expanded
+	return compaction
+}
+
+// checkNoResidualOverlap re-verifies, after expandSources has run, that no
+// SortedRun outside the compaction still overlaps its sources' key range.
+// Tripping this is a bug in expandSources, not an expected condition.
+func (c *CompactorState) checkNoResidualOverlap(compaction Compaction) error {
+	union, ok := c.sourcesUnionRange(compaction.sources)
+	if !ok {
+		return nil
+	}
+	sourceSRs, _ := sourceSets(compaction.sources)
+	for _, sr := range c.dbState.compacted {
+		if sourceSRs[sr.id] {
+			continue
+		}
+		if sortedRunKeyRange(sr).overlaps(union) {
+			return common.ErrInvalidCompaction
+		}
+	}
+	return nil
+}
+
+// sourcesUnionRange resolves each SourceID against the current dbState and
+// returns the union of their key ranges. ok is false if sources is empty or
+// none of its IDs could be resolved (e.g. they have already been consumed
+// by another compaction).
+func (c *CompactorState) sourcesUnionRange(sources []SourceID) (keyRange, bool) {
+	var union keyRange
+	found := false
+	for _, src := range sources {
+		r, ok := c.sourceKeyRange(src)
+		if !ok {
+			continue
+		}
+		if !found {
+			union = r
+			found = true
+		} else {
+			union = union.merge(r)
+		}
+	}
+	return union, found
+}
+
+func (c *CompactorState) sourceKeyRange(src SourceID) (keyRange, bool) {
+	if id, ok := src.sortedRunID().Get(); ok {
+		for _, sr := range c.dbState.compacted {
+			if sr.id == id {
+				return sortedRunKeyRange(sr), true
+			}
+		}
+		return keyRange{}, false
+	}
+	if id, ok := src.sstID().Get(); ok {
+		for _, sst := range c.dbState.l0 {
+			sstID, ok := sst.id.compactedID().Get()
+			if ok && sstID == id {
+				return sstKeyRange(sst), true
+			}
+		}
+		return keyRange{}, false
+	}
+	return keyRange{}, false
+}
+
+func sourceSets(sources []SourceID) (map[uint32]bool, map[ulid.ULID]bool) {
+	srs := make(map[uint32]bool)
+	ssts := make(map[ulid.ULID]bool)
+	for _, src := range sources {
+		if id, ok := src.sortedRunID().Get(); ok {
+			srs[id] = true
+		} else if id, ok := src.sstID().Get(); ok {
+			ssts[id] = true
+		}
+	}
+	return srs, ssts
+}
+
 func (c *CompactorState) oneOfTheSourceSRMatchesDestination(compaction Compaction) bool {
 	for _, src := range compaction.sources {
 		if src.typ == SortedRunID {
@@ -147,6 +474,9 @@ func (c *CompactorState) oneOfTheSourceSRMatchesDestination(compaction Compactio
 }
 
 func (c *CompactorState) refreshDBState(writerState *CoreDBState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	// the writer may have added more l0 SSTs. Add these to our l0 list.
 	lastCompactedL0 := c.dbState.l0LastCompacted
 	mergedL0s := make([]SSTableHandle, 0)
@@ -172,11 +502,15 @@ func (c *CompactorState) refreshDBState(writerState *CoreDBState) {
 // update dbState by removing L0 SSTs and compacted SortedRuns that are present
 // in Compaction.sources
 func (c *CompactorState) finishCompaction(outputSR *SortedRun) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	compaction, ok := c.compactions[outputSR.id]
 	if !ok {
 		return
 	}
 	logger.Info("finished compaction", zap.Any("compaction", compaction))
+	assertNoOverlappingUserKeysAcrossSSTs(*outputSR)
 
 	compactionL0s := make(map[ulid.ULID]bool)
 	compactionSRs := make(map[uint32]bool)
@@ -231,6 +565,18 @@ func (c *CompactorState) finishCompaction(outputSR *SortedRun) {
 	dbState.compacted = newCompacted
 	c.dbState = dbState
 	delete(c.compactions, outputSR.id)
+
+	outputSSTs := make([]string, 0, len(outputSR.sstList))
+	for _, sst := range outputSR.sstList {
+		if id, ok := sst.id.compactedID().Get(); ok {
+			outputSSTs = append(outputSSTs, id.String())
+		}
+	}
+	c.appendManifestRecord(compactionManifestRecord{
+		Kind:        recordFinishCompaction,
+		Destination: outputSR.id,
+		OutputSSTs:  outputSSTs,
+	})
 }
 
 // sortedRun list should have IDs in decreasing order
@@ -241,3 +587,15 @@ func (c *CompactorState) assertCompactedSRsInIDOrder(compacted []SortedRun) {
 		lastSortedRunID = sr.id
 	}
 }
+
+// assertNoOverlappingUserKeysAcrossSSTs guards the invariant expandSources
+// exists to uphold: within a single SortedRun, SSTs must be disjoint and in
+// key order, or Get could return a stale value from the wrong SST.
+func assertNoOverlappingUserKeysAcrossSSTs(sr SortedRun) {
+	for i := 1; i < len(sr.sstList); i++ {
+		prev := sstKeyRange(sr.sstList[i-1])
+		cur := sstKeyRange(sr.sstList[i])
+		common.AssertTrue(bytes.Compare(prev.last, cur.first) < 0,
+			"overlapping user keys across SSTs in the same SortedRun")
+	}
+}
@@ -0,0 +1,96 @@
+package slatedb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/samber/mo"
+	"github.com/thanos-io/objstore"
+)
+
+// DB is the embedded database handle returned by Open. Only the fields this
+// package's compactor/snapshot subsystem needs are modeled here.
+type DB struct {
+	mu             sync.Mutex
+	writerState    *CoreDBState
+	compactorState *CompactorState
+	tableStore     *TableStore
+
+	// nextSeq is the monotonic seqNum stamped on every key at write time;
+	// NewSnapshot captures its current value so reads through the returned
+	// Snapshot are pinned to everything written up to that point.
+	nextSeq atomic.Uint64
+
+	compactor *Compactor
+}
+
+// Open builds a DB over dbState, recovering the compactor's state - any
+// compaction left open by a prior run, resumed or dropped as
+// newCompactorStateFromManifest decides - from its manifest under
+// manifestDir in bucket, and starts its background compactor loop with
+// options.Strategy. The caller must call Close to stop the compactor.
+func Open(
+	ctx context.Context,
+	dbState *CoreDBState,
+	tableStore *TableStore,
+	bucket objstore.Bucket,
+	manifestDir string,
+	options CompactorOptions,
+) (*DB, error) {
+	compactorState, err := newCompactorStateFromManifest(ctx, dbState, bucket, tableStore, manifestDir)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		writerState:    dbState.clone(),
+		compactorState: compactorState,
+		tableStore:     tableStore,
+		compactor:      newCompactor(compactorState, tableStore, bucket, options),
+	}
+	go db.compactor.scheduleCompactions()
+	return db, nil
+}
+
+// Close stops the background compactor loop and cancels any compaction it is
+// running. It waits for the loop to exit, but not for those compactions to
+// return.
+func (db *DB) Close() {
+	db.compactor.close()
+}
+
+// Put writes key=value, stamping the entry with the next seqNum (via
+// encodeInternalKey) so any Snapshot taken after this call observes it and
+// any Snapshot taken before does not. Each Put is flushed as its own L0
+// SST, mirroring how refreshDBState expects writerState.l0 to grow.
+func (db *DB) Put(key []byte, value []byte) (uint64, error) {
+	seq := db.nextSeq.Add(1)
+	internalKey := encodeInternalKey(key, seq)
+
+	writer := db.tableStore.tableWriter(newSSTableIDCompacted(ulid.Make()))
+	if err := writer.add(internalKey, mo.Some(value)); err != nil {
+		return 0, err
+	}
+	sst, err := writer.close()
+	if err != nil {
+		return 0, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.writerState.l0 = append([]SSTableHandle{*sst}, db.writerState.l0...)
+	db.compactorState.refreshDBState(db.writerState)
+	return seq, nil
+}
+
+// NewSnapshot captures the database's current sequence number and returns a
+// Snapshot that reads a consistent point-in-time view as of that point,
+// even as later writes and compactions proceed. The caller must Close it
+// once done, or the compactor will keep every version newer than it around
+// indefinitely.
+func (db *DB) NewSnapshot() *Snapshot {
+	seq := db.nextSeq.Load()
+	return db.compactorState.newSnapshot(seq)
+}
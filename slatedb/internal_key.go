@@ -0,0 +1,34 @@
+package slatedb
+
+import "encoding/binary"
+
+const internalKeySeqLen = 8
+
+// encodeInternalKey appends seq to userKey as an 8-byte big-endian suffix,
+// inverted bitwise so that entries with the same user key sort newest seq
+// first: this lets seq ride through the existing tableBuilder.add(key,
+// value) 2-arg call without changing the SST block format.
+func encodeInternalKey(userKey []byte, seq uint64) []byte {
+	encoded := make([]byte, len(userKey)+internalKeySeqLen)
+	copy(encoded, userKey)
+	binary.BigEndian.PutUint64(encoded[len(userKey):], ^seq)
+	return encoded
+}
+
+// decodeInternalKey splits an internal key back into its user key and seq.
+// A key shorter than internalKeySeqLen (never produced by encodeInternalKey)
+// is returned as-is with seq 0.
+func decodeInternalKey(internalKey []byte) (userKey []byte, seq uint64) {
+	if len(internalKey) < internalKeySeqLen {
+		return internalKey, 0
+	}
+	split := len(internalKey) - internalKeySeqLen
+	return internalKey[:split], ^binary.BigEndian.Uint64(internalKey[split:])
+}
+
+// maxInternalKey returns the internal key that sorts before every encoded
+// version of userKey, i.e. the one a SeekToKey should target to land on the
+// newest version regardless of seq.
+func maxInternalKey(userKey []byte) []byte {
+	return encodeInternalKey(userKey, ^uint64(0))
+}
@@ -0,0 +1,7 @@
+package slatedb
+
+// KeyValue is a single key/value pair read back from a SortedRun iterator.
+type KeyValue struct {
+	Key   []byte
+	Value []byte
+}
@@ -0,0 +1,70 @@
+package slatedb
+
+import "sync"
+
+// Snapshot is a point-in-time read view pinned at a sequence number. While a
+// Snapshot is open, the compactor must not discard any version of a key
+// that was still visible at that seq, even if a newer write or compaction
+// has since superseded it.
+type Snapshot struct {
+	seq      uint64
+	registry *snapshotRegistry
+	once     sync.Once
+}
+
+// Seq returns the sequence number this snapshot reads as of.
+func (s *Snapshot) Seq() uint64 {
+	return s.seq
+}
+
+// Close releases the snapshot, allowing the compactor to reclaim any
+// version that was only being kept alive for it. Close is idempotent.
+func (s *Snapshot) Close() {
+	s.once.Do(func() {
+		s.registry.release(s)
+	})
+}
+
+// snapshotRegistry tracks every open Snapshot so the compactor can compute
+// the oldest sequence number still visible to a reader before a compaction's
+// merge discards superseded versions of a key.
+type snapshotRegistry struct {
+	mu   sync.Mutex
+	open map[*Snapshot]struct{}
+}
+
+func newSnapshotRegistry() *snapshotRegistry {
+	return &snapshotRegistry{open: make(map[*Snapshot]struct{})}
+}
+
+func (r *snapshotRegistry) register(seq uint64) *Snapshot {
+	snapshot := &Snapshot{seq: seq, registry: r}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.open[snapshot] = struct{}{}
+	return snapshot
+}
+
+func (r *snapshotRegistry) release(snapshot *Snapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.open, snapshot)
+}
+
+// minLiveSeq returns the lowest seq among all open snapshots. ok is false
+// if no snapshot is open, meaning no version needs to be preserved for a
+// reader and the compactor is free to drop every superseded version.
+func (r *snapshotRegistry) minLiveSeq() (uint64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	min := uint64(0)
+	found := false
+	for snapshot := range r.open {
+		if !found || snapshot.seq < min {
+			min = snapshot.seq
+			found = true
+		}
+	}
+	return min, found
+}
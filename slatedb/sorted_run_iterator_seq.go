@@ -0,0 +1,96 @@
+package slatedb
+
+import (
+	"bytes"
+
+	"github.com/samber/mo"
+)
+
+// newSortedRunIteratorAtSeq returns an iterator over sr that only surfaces
+// the version of each key that was visible as of seq: for every user key it
+// returns the newest version with an encoded seq <= seq and skips any
+// older version of that same key, so a Snapshot sees a single consistent
+// value per key. It relies on keys in sr having been written through
+// DB.Put, which stamps seq onto the key via encodeInternalKey.
+func newSortedRunIteratorAtSeq(
+	sr SortedRun,
+	tableStore *TableStore,
+	seq uint64,
+	maxFetchTasks uint64,
+	numBlocksToFetch uint64,
+) (*seqFilteredSortedRunIterator, error) {
+	inner := newSortedRunIterator(sr, tableStore, maxFetchTasks, numBlocksToFetch)
+	return &seqFilteredSortedRunIterator{inner: inner, seq: seq}, nil
+}
+
+// newSortedRunIteratorAtSeqFromKey is the seq-aware counterpart to
+// newSortedRunIteratorFromKey: it seeks to the newest version of fromKey
+// visible as of seq and iterates forward from there.
+func newSortedRunIteratorAtSeqFromKey(
+	fromKey []byte,
+	sr SortedRun,
+	tableStore *TableStore,
+	seq uint64,
+	maxFetchTasks uint64,
+	numBlocksToFetch uint64,
+) (*seqFilteredSortedRunIterator, error) {
+	it, err := newSortedRunIteratorAtSeq(sr, tableStore, seq, maxFetchTasks, numBlocksToFetch)
+	if err != nil {
+		return nil, err
+	}
+	if err := it.SeekToKey(fromKey); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// seqFilteredSortedRunIterator wraps a SortedRunIterator reading
+// seq-stamped internal keys and hides every version newer than the
+// snapshot seq it was opened at, decoding internal keys back to plain user
+// keys as it goes.
+type seqFilteredSortedRunIterator struct {
+	inner *SortedRunIterator
+	seq   uint64
+
+	lastUserKey []byte
+	haveLast    bool
+}
+
+func (it *seqFilteredSortedRunIterator) Next() (mo.Option[KeyValue], error) {
+	for {
+		kv, err := it.inner.Next()
+		if err != nil || kv.IsAbsent() {
+			return mo.None[KeyValue](), err
+		}
+		raw, _ := kv.Get()
+		userKey, entrySeq := decodeInternalKey(raw.Key)
+
+		if it.haveLast && bytes.Equal(userKey, it.lastUserKey) {
+			// an older version of a key we've already returned; keep
+			// scanning past it.
+			continue
+		}
+		if entrySeq > it.seq {
+			// a version written after this snapshot; the next, older
+			// version of the same key (if any) is what this snapshot
+			// should see instead.
+			continue
+		}
+
+		it.lastUserKey = userKey
+		it.haveLast = true
+		return mo.Some(KeyValue{Key: userKey, Value: raw.Value}), nil
+	}
+}
+
+// SeekToKey repositions the iterator at the newest version of key visible
+// as of seq.
+func (it *seqFilteredSortedRunIterator) SeekToKey(key []byte) error {
+	it.haveLast = false
+	return it.inner.SeekToKey(maxInternalKey(key))
+}
+
+// Close releases resources held by the underlying SortedRunIterator.
+func (it *seqFilteredSortedRunIterator) Close() error {
+	return it.inner.Close()
+}
@@ -0,0 +1,162 @@
+package slatedb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/samber/mo"
+	"github.com/stretchr/testify/assert"
+	"github.com/thanos-io/objstore"
+)
+
+func TestSeqFilteredSRIter(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	format := newSSTableFormat(4096, 3, CompressionNone)
+	tableStore := newTableStore(bucket, format, "")
+
+	// key1 has two versions; key1-v2 (seq 2) must sort before key1-v1
+	// (seq 1) since encodeInternalKey inverts seq, newest first.
+	builder := tableStore.tableBuilder()
+	builder.add(encodeInternalKey([]byte("key1"), 2), mo.Some([]byte("key1-v2")))
+	builder.add(encodeInternalKey([]byte("key1"), 1), mo.Some([]byte("key1-v1")))
+	builder.add(encodeInternalKey([]byte("key2"), 1), mo.Some([]byte("key2-v1")))
+
+	encodedSST, err := builder.build()
+	assert.NoError(t, err)
+	sstHandle, err := tableStore.writeSST(newSSTableIDCompacted(ulid.Make()), encodedSST)
+	assert.NoError(t, err)
+
+	sr := SortedRun{0, []SSTableHandle{*sstHandle}}
+
+	iter, err := newSortedRunIteratorAtSeq(sr, tableStore, 2, 1, 1)
+	assert.NoError(t, err)
+	assertSeqIterNext(t, iter, []byte("key1"), []byte("key1-v2"))
+	assertSeqIterNext(t, iter, []byte("key2"), []byte("key2-v1"))
+	next, err := iter.Next()
+	assert.NoError(t, err)
+	assert.True(t, next.IsAbsent())
+
+	iter, err = newSortedRunIteratorAtSeq(sr, tableStore, 1, 1, 1)
+	assert.NoError(t, err)
+	assertSeqIterNext(t, iter, []byte("key1"), []byte("key1-v1"))
+	assertSeqIterNext(t, iter, []byte("key2"), []byte("key2-v1"))
+	next, err = iter.Next()
+	assert.NoError(t, err)
+	assert.True(t, next.IsAbsent())
+}
+
+func TestSeqFilteredSRIterFromKey(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	format := newSSTableFormat(4096, 3, CompressionNone)
+	tableStore := newTableStore(bucket, format, "")
+
+	builder := tableStore.tableBuilder()
+	builder.add(encodeInternalKey([]byte("key1"), 2), mo.Some([]byte("key1-v2")))
+	builder.add(encodeInternalKey([]byte("key1"), 1), mo.Some([]byte("key1-v1")))
+	builder.add(encodeInternalKey([]byte("key2"), 1), mo.Some([]byte("key2-v1")))
+
+	encodedSST, err := builder.build()
+	assert.NoError(t, err)
+	sstHandle, err := tableStore.writeSST(newSSTableIDCompacted(ulid.Make()), encodedSST)
+	assert.NoError(t, err)
+
+	sr := SortedRun{0, []SSTableHandle{*sstHandle}}
+
+	iter, err := newSortedRunIteratorAtSeqFromKey([]byte("key2"), sr, tableStore, 2, 1, 1)
+	assert.NoError(t, err)
+	assertSeqIterNext(t, iter, []byte("key2"), []byte("key2-v1"))
+	next, err := iter.Next()
+	assert.NoError(t, err)
+	assert.True(t, next.IsAbsent())
+}
+
+func assertSeqIterNext(t *testing.T, iter *seqFilteredSortedRunIterator, expectedKey, expectedVal []byte) {
+	t.Helper()
+	kv, err := iter.Next()
+	assert.NoError(t, err)
+	assert.True(t, kv.IsPresent())
+	got, _ := kv.Get()
+	assert.Equal(t, expectedKey, got.Key)
+	assert.Equal(t, expectedVal, got.Value)
+}
+
+// TestCompactionRetainsVersionVisibleToSnapshot checks that a compaction run
+// while a snapshot is open keeps the older version of a key that snapshot
+// would read. key1 is written at seq 3, a snapshot is held at seq 5 (pinning
+// minLiveSeq at 5), and key1 is then overwritten at seq 10. The merged
+// SortedRun must keep v3 - the version visible at seq 5 - even though v10 is
+// newer and v3 is no longer the latest.
+func TestCompactionRetainsVersionVisibleToSnapshot(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	format := newSSTableFormat(4096, 3, CompressionNone)
+	tableStore := newTableStore(bucket, format, "")
+
+	baseBuilder := tableStore.tableBuilder()
+	baseBuilder.add(encodeInternalKey([]byte("key1"), 3), mo.Some([]byte("v3")))
+	baseBuilder.add(encodeInternalKey([]byte("key2"), 1), mo.Some([]byte("key2-v1")))
+	baseEncoded, err := baseBuilder.build()
+	assert.NoError(t, err)
+	baseSST, err := tableStore.writeSST(newSSTableIDCompacted(ulid.Make()), baseEncoded)
+	assert.NoError(t, err)
+	baseSR := SortedRun{id: 1, sstList: []SSTableHandle{*baseSST}}
+
+	newBuilder := tableStore.tableBuilder()
+	newBuilder.add(encodeInternalKey([]byte("key1"), 10), mo.Some([]byte("v10")))
+	newEncoded, err := newBuilder.build()
+	assert.NoError(t, err)
+	newSSTID := ulid.Make()
+	newSST, err := tableStore.writeSST(newSSTableIDCompacted(newSSTID), newEncoded)
+	assert.NoError(t, err)
+
+	dbState := &CoreDBState{
+		l0:        []SSTableHandle{*newSST},
+		compacted: []SortedRun{baseSR},
+	}
+	state := newCompactorState(dbState)
+	snapshot := state.newSnapshot(5)
+	defer snapshot.Close()
+
+	compaction := newCompaction([]SourceID{newSourceIDSST(newSSTID), newSourceIDSortedRun(baseSR.id)}, baseSR.id)
+	state.compactions[compaction.destination] = compaction
+
+	executor := newCompactionExecutor(state, tableStore, bucket)
+	executor.run(context.Background(), compaction.destination)
+
+	merged := state.dbStateView().compacted
+	assert.Len(t, merged, 1)
+	iter := newSortedRunIterator(merged[0], tableStore, 1, 1)
+
+	// newest version of key1 (seq 10) sorts first.
+	kv, err := iter.Next()
+	assert.NoError(t, err)
+	assert.True(t, kv.IsPresent())
+	got, _ := kv.Get()
+	userKey, seq := decodeInternalKey(got.Key)
+	assert.Equal(t, []byte("key1"), userKey)
+	assert.Equal(t, uint64(10), seq)
+	assert.Equal(t, []byte("v10"), got.Value)
+
+	// the version visible to the snapshot taken at seq 5 (seq 3) must
+	// survive the compaction, even though it is no longer the newest.
+	kv, err = iter.Next()
+	assert.NoError(t, err)
+	assert.True(t, kv.IsPresent())
+	got, _ = kv.Get()
+	userKey, seq = decodeInternalKey(got.Key)
+	assert.Equal(t, []byte("key1"), userKey)
+	assert.Equal(t, uint64(3), seq)
+	assert.Equal(t, []byte("v3"), got.Value)
+
+	kv, err = iter.Next()
+	assert.NoError(t, err)
+	assert.True(t, kv.IsPresent())
+	got, _ = kv.Get()
+	userKey, _ = decodeInternalKey(got.Key)
+	assert.Equal(t, []byte("key2"), userKey)
+	assert.Equal(t, []byte("key2-v1"), got.Value)
+
+	kv, err = iter.Next()
+	assert.NoError(t, err)
+	assert.True(t, kv.IsAbsent())
+}